@@ -0,0 +1,245 @@
+package object
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+
+	"go.flipt.io/flipt/internal/containers"
+	storagefs "go.flipt.io/flipt/internal/storage/fs"
+	gcblob "gocloud.dev/blob"
+)
+
+// WithBundleKey configures the SnapshotStore to treat the object at this key
+// (relative to the configured prefix) as a single compressed archive
+// containing the entire Flipt configuration, rather than listing and
+// fetching individual objects under the prefix. This lets deployments with
+// many flag files publish a snapshot atomically by replacing one object,
+// instead of paying for an O(N) LIST+GET against the bucket on every poll.
+// Supported archive formats are tar.gz/tgz and zip, detected from key's
+// suffix or, failing that, the object's content type.
+func WithBundleKey(key string) containers.Option[SnapshotStore] {
+	return func(s *SnapshotStore) {
+		s.bundleKey = key
+	}
+}
+
+// buildFromBundle fetches and extracts the configured bundle object,
+// synthesizing an fs.File per archive member. It reuses the revision cache
+// keyed by the bundle's full bucket key, so an unchanged bundle is skipped entirely
+// without re-downloading or re-extracting it.
+func (s *SnapshotStore) buildFromBundle(ctx context.Context) (buildResult, error) {
+	key := s.prefix + s.bundleKey
+
+	attrs, err := s.bucket.Attributes(ctx, key)
+	if err != nil {
+		return buildResult{}, err
+	}
+
+	version := objectVersionFromAttrs(attrs)
+
+	s.mu.RLock()
+	prev, ok := s.revisions[key]
+	s.mu.RUnlock()
+
+	if ok && prev.version == version {
+		s.metrics.hits.Add(ctx, 1)
+		return buildResult{}, nil
+	}
+
+	rd, err := s.bucket.NewReader(ctx, key, &gcblob.ReaderOptions{})
+	if err != nil {
+		return buildResult{}, err
+	}
+
+	data, err := io.ReadAll(rd)
+	rd.Close()
+	if err != nil {
+		return buildResult{}, err
+	}
+
+	members, err := extractBundle(s.bundleKey, attrs.ContentType, data, attrs.ModTime)
+	if err != nil {
+		return buildResult{}, fmt.Errorf("extracting bundle %q: %w", s.bundleKey, err)
+	}
+
+	idx, err := indexFromBundle(members)
+	if err != nil {
+		return buildResult{}, err
+	}
+
+	var files []fs.File
+	for _, m := range members {
+		if !idx.Match(m.key) {
+			continue
+		}
+		files = append(files, NewFile(m.key, int64(len(m.data)), io.NopCloser(bytes.NewReader(m.data)), m.modTime))
+	}
+
+	snap, err := storagefs.SnapshotFromFiles(s.logger, files...)
+	if err != nil {
+		return buildResult{}, err
+	}
+
+	if ok {
+		s.metrics.changed.Add(ctx, 1)
+	} else {
+		s.metrics.misses.Add(ctx, 1)
+	}
+
+	s.mu.Lock()
+	s.revisions = map[string]objectRevision{
+		key: {version: version, modTime: attrs.ModTime, size: attrs.Size, data: data},
+	}
+	s.mu.Unlock()
+
+	revision := versionIDFromAttrs(attrs)
+	if revision == "" {
+		revision = revisionFromModTime(attrs.ModTime)
+	}
+
+	return buildResult{snap: snap, changed: true, revision: revision, modTime: attrs.ModTime, versionKey: key}, nil
+}
+
+// bundleMember is a single file synthesized from an archive entry.
+type bundleMember struct {
+	key     string
+	modTime time.Time
+	data    []byte
+}
+
+// extractBundle detects the archive format from key's suffix, falling back
+// to contentType, and extracts its members.
+func extractBundle(key, contentType string, data []byte, fallbackModTime time.Time) ([]bundleMember, error) {
+	switch {
+	case strings.HasSuffix(key, ".tar.gz") || strings.HasSuffix(key, ".tgz"):
+		return extractTarGz(data, fallbackModTime)
+	case strings.HasSuffix(key, ".zip"):
+		return extractZip(data, fallbackModTime)
+	case strings.Contains(contentType, "gzip"):
+		return extractTarGz(data, fallbackModTime)
+	case strings.Contains(contentType, "zip"):
+		return extractZip(data, fallbackModTime)
+	default:
+		return nil, fmt.Errorf("unrecognized bundle format for %q (content-type %q)", key, contentType)
+	}
+}
+
+func extractTarGz(data []byte, fallbackModTime time.Time) ([]bundleMember, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+
+	var members []bundleMember
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		if hdr.FileInfo().IsDir() {
+			continue
+		}
+
+		name, ok := sanitizedMemberName(hdr.Name)
+		if !ok {
+			return nil, fmt.Errorf("invalid archive member name %q", hdr.Name)
+		}
+
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		modTime := hdr.ModTime
+		if modTime.IsZero() {
+			modTime = fallbackModTime
+		}
+
+		members = append(members, bundleMember{key: name, modTime: modTime, data: contents})
+	}
+
+	return members, nil
+}
+
+func extractZip(data []byte, fallbackModTime time.Time) ([]bundleMember, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	var members []bundleMember
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		name, ok := sanitizedMemberName(f.Name)
+		if !ok {
+			return nil, fmt.Errorf("invalid archive member name %q", f.Name)
+		}
+
+		rd, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		contents, err := io.ReadAll(rd)
+		rd.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		modTime := f.Modified
+		if modTime.IsZero() {
+			modTime = fallbackModTime
+		}
+
+		members = append(members, bundleMember{key: name, modTime: modTime, data: contents})
+	}
+
+	return members, nil
+}
+
+// sanitizedMemberName validates an archive entry's name before it is used
+// to synthesize an fs.File. Archive formats don't constrain member names
+// the way fs.FS does, so a bundle fetched from the configured bucket could
+// otherwise smuggle in "..", an absolute path, or other fs.ValidPath
+// violations. A leading "./", which tar writers commonly emit, is
+// tolerated and stripped.
+func sanitizedMemberName(name string) (string, bool) {
+	name = strings.TrimPrefix(name, "./")
+	if !fs.ValidPath(name) {
+		return "", false
+	}
+
+	return name, true
+}
+
+// indexFromBundle looks for an embedded .flipt.yml index among the archive
+// members, falling back to the package default when the bundle doesn't
+// include one.
+func indexFromBundle(members []bundleMember) (*storagefs.FliptIndex, error) {
+	for _, m := range members {
+		if m.key == storagefs.IndexFileName {
+			return storagefs.ParseFliptIndex(bytes.NewReader(m.data))
+		}
+	}
+
+	return storagefs.DefaultFliptIndex()
+}
@@ -0,0 +1,165 @@
+package object
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+)
+
+func buildTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, contents := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name: name,
+			Size: int64(len(contents)),
+			Mode: 0o644,
+		}); err != nil {
+			t.Fatalf("writing tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatalf("writing tar contents: %v", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("closing tar writer: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("closing gzip writer: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("creating zip entry: %v", err)
+		}
+		if _, err := w.Write([]byte(contents)); err != nil {
+			t.Fatalf("writing zip contents: %v", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("closing zip writer: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestExtractTarGz(t *testing.T) {
+	data := buildTarGz(t, map[string]string{
+		"flags.yml":      "flag: true",
+		"segments/a.yml": "segment: a",
+		".flipt.yml":     "index: true",
+	})
+
+	members, err := extractTarGz(data, time.Now())
+	if err != nil {
+		t.Fatalf("extractTarGz() error = %v", err)
+	}
+
+	if len(members) != 3 {
+		t.Fatalf("len(members) = %d, want 3", len(members))
+	}
+
+	byKey := make(map[string]bundleMember, len(members))
+	for _, m := range members {
+		byKey[m.key] = m
+	}
+
+	if string(byKey["flags.yml"].data) != "flag: true" {
+		t.Errorf("flags.yml contents = %q", byKey["flags.yml"].data)
+	}
+	if _, ok := byKey["segments/a.yml"]; !ok {
+		t.Errorf("missing segments/a.yml in extracted members")
+	}
+}
+
+func TestExtractTarGz_RejectsInvalidMemberNames(t *testing.T) {
+	for _, name := range []string{"../escape.yml", "/etc/passwd", ""} {
+		data := buildTarGz(t, map[string]string{name: "oops"})
+
+		if _, err := extractTarGz(data, time.Now()); err == nil {
+			t.Errorf("extractTarGz() with member %q: expected error, got nil", name)
+		}
+	}
+}
+
+func TestExtractTarGz_StripsLeadingDotSlash(t *testing.T) {
+	data := buildTarGz(t, map[string]string{"./flags.yml": "flag: true"})
+
+	members, err := extractTarGz(data, time.Now())
+	if err != nil {
+		t.Fatalf("extractTarGz() error = %v", err)
+	}
+
+	if len(members) != 1 || members[0].key != "flags.yml" {
+		t.Fatalf("members = %+v, want a single flags.yml entry", members)
+	}
+}
+
+func TestExtractZip(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"flags.yml":      "flag: true",
+		"segments/a.yml": "segment: a",
+	})
+
+	members, err := extractZip(data, time.Now())
+	if err != nil {
+		t.Fatalf("extractZip() error = %v", err)
+	}
+
+	if len(members) != 2 {
+		t.Fatalf("len(members) = %d, want 2", len(members))
+	}
+}
+
+func TestExtractZip_RejectsInvalidMemberNames(t *testing.T) {
+	data := buildZip(t, map[string]string{"../escape.yml": "oops"})
+
+	if _, err := extractZip(data, time.Now()); err == nil {
+		t.Errorf("extractZip() with traversal member: expected error, got nil")
+	}
+}
+
+func TestSanitizedMemberName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantKey string
+		wantOK  bool
+	}{
+		{name: "flags.yml", wantKey: "flags.yml", wantOK: true},
+		{name: "./flags.yml", wantKey: "flags.yml", wantOK: true},
+		{name: "segments/a.yml", wantKey: "segments/a.yml", wantOK: true},
+		{name: "../escape.yml", wantOK: false},
+		{name: "/etc/passwd", wantOK: false},
+		{name: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		key, ok := sanitizedMemberName(tt.name)
+		if ok != tt.wantOK {
+			t.Errorf("sanitizedMemberName(%q) ok = %v, want %v", tt.name, ok, tt.wantOK)
+			continue
+		}
+		if ok && key != tt.wantKey {
+			t.Errorf("sanitizedMemberName(%q) = %q, want %q", tt.name, key, tt.wantKey)
+		}
+	}
+}
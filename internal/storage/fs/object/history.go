@@ -0,0 +1,293 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	gcsstorage "cloud.google.com/go/storage"
+	s3v2 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.flipt.io/flipt/internal/containers"
+	"go.flipt.io/flipt/internal/storage"
+	"go.uber.org/zap"
+	"google.golang.org/api/iterator"
+)
+
+// RevisionInfo describes one retained snapshot, as returned by Revisions.
+type RevisionInfo struct {
+	// Revision is an opaque identifier for this retained snapshot: the
+	// index object's version id when the bucket reports one (a hash
+	// combining every configured prefix's index version when more than one
+	// prefix is configured), or the max ModTime observed across matched
+	// objects during the build that produced it, formatted as RFC3339Nano.
+	// With WithBundleKey, the bundle object's version id or ModTime is used
+	// instead.
+	Revision string
+	ModTime  time.Time
+}
+
+// retainedSnapshot pairs a RevisionInfo with the snapshot it describes. snap
+// is nil for a revision known only from the bucket's own version history
+// (see indexVersions) that this process never built itself; ViewAt reports
+// an error for those rather than pretending to serve their content.
+type retainedSnapshot struct {
+	RevisionInfo
+	snap storage.ReadOnlyStore
+}
+
+// WithHistory configures the SnapshotStore to retain the last n
+// successfully-built snapshots, so ViewAt can serve a prior known-good
+// configuration without the caller needing to touch the bucket. The
+// default is 1, meaning only the current snapshot is kept.
+//
+// When WithBucketName is also configured and the bucket has S3 or GCS
+// object versioning enabled, Revisions() additionally reflects versions of
+// the tracked object (the index file with a single prefix, or the bundle
+// object with WithBundleKey) that the bucket itself has stored, not just
+// the ones this process happened to build while running; see indexVersions.
+// No bucket history is consulted when several prefixes are configured, and
+// the merged list is still trimmed to the newest n entries.
+func WithHistory(n int) containers.Option[SnapshotStore] {
+	return func(s *SnapshotStore) {
+		s.history = n
+	}
+}
+
+// WithBucketName configures the bucket's own name, which gocloud's bucket
+// abstraction deliberately doesn't expose but the cloud provider SDKs
+// require to call their native object-version APIs directly (S3
+// ListObjectVersions, GCS object generations). Required for WithHistory to
+// produce a revision list backed by the bucket's real version history
+// instead of approximating it from whichever builds happened to run while
+// this process was up.
+func WithBucketName(name string) containers.Option[SnapshotStore] {
+	return func(s *SnapshotStore) {
+		s.bucketName = name
+	}
+}
+
+// historyLimit returns the configured retention depth, or 1 if unset.
+func (s *SnapshotStore) historyLimit() int {
+	if s.history <= 0 {
+		return 1
+	}
+
+	return s.history
+}
+
+// retain records a newly built snapshot in the retention history, then, if
+// versionKey identifies an object whose version history the bucket can
+// enumerate, reconciles the retained list against it so Revisions()
+// reflects every version the bucket actually has rather than only the ones
+// this process built. Retained snapshots are kept newest-first and trimmed
+// to the configured limit.
+func (s *SnapshotStore) retain(ctx context.Context, revision string, modTime time.Time, snap storage.ReadOnlyStore, versionKey string) {
+	// Enumerated before taking s.mu: it's a real S3/GCS API call, and
+	// View/ViewAt/Revisions/ObjectRevisions all take s.mu for reads, so
+	// holding it across the call would stall every flag evaluation for as
+	// long as the bucket takes to answer.
+	var versions []RevisionInfo
+	if versionKey != "" {
+		var err error
+		versions, err = s.indexVersions(ctx, versionKey)
+		if err != nil {
+			s.logger.Warn("listing bucket version history", zap.String("key", versionKey), zap.Error(err))
+			versions = nil
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.retained = append([]retainedSnapshot{{
+		RevisionInfo: RevisionInfo{Revision: revision, ModTime: modTime},
+		snap:         snap,
+	}}, s.retained...)
+
+	if versions != nil {
+		s.mergeVersionHistoryLocked(versions)
+	}
+
+	if limit := s.historyLimit(); len(s.retained) > limit {
+		s.retained = s.retained[:limit]
+	}
+}
+
+// mergeVersionHistoryLocked merges the bucket's own object-version history
+// for the tracked key into the retained list, inserting a metadata-only
+// entry (snap == nil) for any version this process hasn't built itself, and
+// re-sorting newest-first. Callers must hold s.mu.
+func (s *SnapshotStore) mergeVersionHistoryLocked(versions []RevisionInfo) {
+	known := make(map[string]struct{}, len(s.retained))
+	for _, r := range s.retained {
+		known[r.Revision] = struct{}{}
+	}
+
+	for _, v := range versions {
+		if _, ok := known[v.Revision]; ok {
+			continue
+		}
+		s.retained = append(s.retained, retainedSnapshot{RevisionInfo: v})
+		known[v.Revision] = struct{}{}
+	}
+
+	sort.Slice(s.retained, func(i, j int) bool {
+		return s.retained[i].ModTime.After(s.retained[j].ModTime)
+	})
+}
+
+// revisionFromModTime derives a RevisionInfo.Revision for buckets which
+// don't report an object-version-id.
+func revisionFromModTime(modTime time.Time) string {
+	return modTime.UTC().Format(time.RFC3339Nano)
+}
+
+// indexVersions enumerates every retained version of the object at key via
+// the bucket's native object-versioning API (S3 ListObjectVersions, or GCS
+// object generations), newest first. It returns (nil, nil) when
+// WithBucketName wasn't configured, the underlying bucket driver is
+// neither S3 nor GCS, or an S3 bucket has versioning disabled, in which case
+// callers fall back to the runtime-accumulated history recorded by retain.
+// A GCS bucket without versioning reports only the live generation.
+func (s *SnapshotStore) indexVersions(ctx context.Context, key string) ([]RevisionInfo, error) {
+	if s.bucketName == "" {
+		return nil, nil
+	}
+
+	if versions, ok, err := s.indexVersionsS3(ctx, key); ok {
+		return versions, err
+	}
+
+	if versions, ok, err := s.indexVersionsGCS(ctx, key); ok {
+		return versions, err
+	}
+
+	return nil, nil
+}
+
+// indexVersionsS3 lists every version of key via S3's ListObjectVersions.
+// The bool return reports whether the bucket is backed by an S3 client at
+// all, so indexVersions knows whether to try the GCS path instead.
+func (s *SnapshotStore) indexVersionsS3(ctx context.Context, key string) ([]RevisionInfo, bool, error) {
+	var client *s3v2.Client
+	if !s.bucket.As(&client) {
+		return nil, false, nil
+	}
+
+	var (
+		versions      []RevisionInfo
+		keyMarker     *string
+		versionMarker *string
+	)
+
+	for {
+		out, err := client.ListObjectVersions(ctx, &s3v2.ListObjectVersionsInput{
+			Bucket:          &s.bucketName,
+			Prefix:          &key,
+			KeyMarker:       keyMarker,
+			VersionIdMarker: versionMarker,
+		})
+		if err != nil {
+			return nil, true, err
+		}
+
+		for _, v := range out.Versions {
+			// An unversioned bucket reports the version id "null", which
+			// identifies no particular revision.
+			if v.Key == nil || *v.Key != key || v.VersionId == nil || *v.VersionId == "null" || v.LastModified == nil {
+				continue
+			}
+			versions = append(versions, RevisionInfo{Revision: *v.VersionId, ModTime: *v.LastModified})
+		}
+
+		if out.IsTruncated == nil || !*out.IsTruncated {
+			break
+		}
+
+		keyMarker = out.NextKeyMarker
+		versionMarker = out.NextVersionIdMarker
+	}
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i].ModTime.After(versions[j].ModTime) })
+
+	return versions, true, nil
+}
+
+// indexVersionsGCS lists every generation of key via GCS's Versions query.
+// The bool return reports whether the bucket is backed by a GCS client at
+// all, so indexVersions knows there's no further driver to try.
+func (s *SnapshotStore) indexVersionsGCS(ctx context.Context, key string) ([]RevisionInfo, bool, error) {
+	var client *gcsstorage.Client
+	if !s.bucket.As(&client) {
+		return nil, false, nil
+	}
+
+	it := client.Bucket(s.bucketName).Objects(ctx, &gcsstorage.Query{Prefix: key, Versions: true})
+
+	var versions []RevisionInfo
+	for {
+		attrs, err := it.Next()
+		if err != nil {
+			if errors.Is(err, iterator.Done) {
+				break
+			}
+			return nil, true, err
+		}
+
+		if attrs.Name != key {
+			continue
+		}
+
+		versions = append(versions, RevisionInfo{
+			Revision: strconv.FormatInt(attrs.Generation, 10),
+			ModTime:  attrs.Updated,
+		})
+	}
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i].ModTime.After(versions[j].ModTime) })
+
+	return versions, true, nil
+}
+
+// Revisions returns metadata for each snapshot currently retained, newest
+// first. When the bucket's version history is available (see
+// WithBucketName), this includes revisions this process never built
+// itself, identified by the bucket's own version ids.
+func (s *SnapshotStore) Revisions() []RevisionInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]RevisionInfo, len(s.retained))
+	for i, r := range s.retained {
+		infos[i] = r.RevisionInfo
+	}
+
+	return infos
+}
+
+// ViewAt accepts a function which takes a storage.ReadOnlyStore for the
+// most recent retained snapshot whose ModTime is at or before ts. It
+// returns an error if no such snapshot is retained, which can happen if ts
+// predates the store's history window and WithHistory wasn't configured
+// deeply enough to cover it, or if the matched revision is one Revisions()
+// reports from the bucket's own version history (see WithBucketName) but
+// this process never built itself, so there's no snapshot content to serve
+// for it.
+func (s *SnapshotStore) ViewAt(ts time.Time, fn func(storage.ReadOnlyStore) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, r := range s.retained {
+		if !r.ModTime.After(ts) {
+			if r.snap == nil {
+				return fmt.Errorf("revision %s at %s is known from the bucket's version history but was never built by this process, so its content isn't available", r.Revision, r.ModTime)
+			}
+			return fn(r.snap)
+		}
+	}
+
+	return fmt.Errorf("no retained snapshot at or before %s", ts)
+}
@@ -0,0 +1,72 @@
+package object
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.flipt.io/flipt/internal/storage"
+	"go.uber.org/zap"
+)
+
+func TestMergeVersionHistoryLocked_InsertsUnbuiltVersionsAsMetadataOnly(t *testing.T) {
+	now := time.Now()
+
+	s := &SnapshotStore{logger: zap.NewNop()}
+	s.retained = []retainedSnapshot{
+		{RevisionInfo: RevisionInfo{Revision: "v2", ModTime: now}},
+	}
+
+	s.mergeVersionHistoryLocked([]RevisionInfo{
+		{Revision: "v2", ModTime: now},
+		{Revision: "v1", ModTime: now.Add(-time.Hour)},
+	})
+
+	if len(s.retained) != 2 {
+		t.Fatalf("len(s.retained) = %d, want 2", len(s.retained))
+	}
+
+	if s.retained[0].Revision != "v2" || s.retained[1].Revision != "v1" {
+		t.Errorf("retained = %+v, want v2 then v1 (newest first)", s.retained)
+	}
+
+	if s.retained[1].snap != nil {
+		t.Error("a version merged in from bucket history should be metadata-only (snap == nil)")
+	}
+}
+
+func TestMergeVersionHistoryLocked_SkipsAlreadyRetainedRevisions(t *testing.T) {
+	now := time.Now()
+
+	s := &SnapshotStore{logger: zap.NewNop()}
+	s.retained = []retainedSnapshot{
+		{RevisionInfo: RevisionInfo{Revision: "v1", ModTime: now}},
+	}
+
+	s.mergeVersionHistoryLocked([]RevisionInfo{{Revision: "v1", ModTime: now}})
+
+	if len(s.retained) != 1 {
+		t.Fatalf("len(s.retained) = %d, want 1 (already-retained revision shouldn't duplicate)", len(s.retained))
+	}
+}
+
+func TestViewAt_ErrorsForMetadataOnlyRevision(t *testing.T) {
+	now := time.Now()
+
+	s := &SnapshotStore{logger: zap.NewNop()}
+	s.retained = []retainedSnapshot{
+		{RevisionInfo: RevisionInfo{Revision: "v1", ModTime: now}},
+	}
+
+	err := s.ViewAt(now, func(storage.ReadOnlyStore) error {
+		t.Fatal("fn should not be called for a metadata-only revision")
+		return nil
+	})
+	if err == nil {
+		t.Fatal("ViewAt() error = nil, want an error for a metadata-only revision")
+	}
+
+	if !strings.Contains(err.Error(), "never built") {
+		t.Errorf("ViewAt() error = %q, want it to explain the revision was never built", err.Error())
+	}
+}
@@ -0,0 +1,45 @@
+package object
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var meter = otel.Meter("go.flipt.io/flipt/internal/storage/fs/object")
+
+// storeMetrics holds the counters instrumenting SnapshotStore.build, so
+// operators can see how effective the revision cache is at avoiding
+// unnecessary object fetches.
+type storeMetrics struct {
+	hits    metric.Int64Counter
+	misses  metric.Int64Counter
+	changed metric.Int64Counter
+}
+
+func newStoreMetrics() (*storeMetrics, error) {
+	hits, err := meter.Int64Counter(
+		"flipt.storage.fs.object.revision_hits",
+		metric.WithDescription("Number of objects reused unchanged across snapshot builds"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	misses, err := meter.Int64Counter(
+		"flipt.storage.fs.object.revision_misses",
+		metric.WithDescription("Number of objects fetched for the first time during a snapshot build"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := meter.Int64Counter(
+		"flipt.storage.fs.object.revision_changed",
+		metric.WithDescription("Number of previously seen objects that changed during a snapshot build"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storeMetrics{hits: hits, misses: misses, changed: changed}, nil
+}
@@ -0,0 +1,240 @@
+package object
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.flipt.io/flipt/internal/containers"
+	"go.uber.org/zap"
+	"gocloud.dev/pubsub"
+
+	// register the awssnssqs and gcppubsub URL openers so SubscriptionURL
+	// can point at either an SQS queue or a GCS Pub/Sub subscription
+	_ "gocloud.dev/pubsub/awssnssqs"
+	_ "gocloud.dev/pubsub/gcppubsub"
+)
+
+// ChangeNotifications configures an event-driven source of bucket change
+// notifications for a SnapshotStore, so it can refresh its snapshot as soon
+// as an object changes instead of waiting on the next poll.
+type ChangeNotifications struct {
+	// SubscriptionURL is a gocloud.dev/pubsub subscription URL.
+	// Use an "awssqs://" URL for an SQS queue fed by S3 bucket
+	// notifications, or a "gcppubsub://" URL for a GCS Pub/Sub
+	// subscription fed by Cloud Storage notifications.
+	SubscriptionURL string
+	// Debounce collapses bursts of notifications arriving within this
+	// window into a single snapshot update.
+	Debounce time.Duration
+	// FallbackGrace is how long the notification stream may keep failing
+	// to receive before the SnapshotStore logs that it is relying on the
+	// poller until the stream recovers. A subscription that cannot be
+	// opened at all is logged once and not retried.
+	FallbackGrace time.Duration
+}
+
+// WithChangeNotifications configures the SnapshotStore to subscribe to bucket
+// change notifications and refresh its snapshot in response, alongside its
+// regular Poller. If the notification stream errors or disconnects, the
+// Poller continues to run and serves as the fallback refresh mechanism.
+func WithChangeNotifications(cfg ChangeNotifications) containers.Option[SnapshotStore] {
+	return func(s *SnapshotStore) {
+		s.changeNotifications = &cfg
+	}
+}
+
+// receiveRetryBackoff is how long notify waits between Receive retries
+// after an error, so a persistently broken subscription doesn't spin the
+// consumer goroutine in a tight loop.
+const receiveRetryBackoff = time.Second
+
+// unhealthTracker decides when notify should warn that the change
+// notification stream is down, so a persistently failing Receive logs the
+// warning once per unhealthy period rather than on every retry.
+type unhealthTracker struct {
+	since  time.Time
+	warned bool
+}
+
+// observeError records a failed Receive at t and reports whether notify
+// should log the "relying on poller" warning now.
+func (h *unhealthTracker) observeError(t time.Time, grace time.Duration) bool {
+	if h.since.IsZero() {
+		h.since = t
+		return false
+	}
+
+	if h.warned {
+		return false
+	}
+
+	if t.Sub(h.since) > grace {
+		h.warned = true
+		return true
+	}
+
+	return false
+}
+
+// observeSuccess resets tracking after a successful Receive.
+func (h *unhealthTracker) observeSuccess() {
+	h.since = time.Time{}
+	h.warned = false
+}
+
+// debouncer coalesces bursts of calls to trigger arriving within window
+// into a single invocation of fn, run once window elapses without a
+// further call.
+type debouncer struct {
+	timer *time.Timer
+}
+
+// trigger schedules fn to run after window, resetting the window if a
+// trigger is already pending. Only the fn from the first call is kept:
+// every later call re-arms the timer for that same fn.
+func (d *debouncer) trigger(window time.Duration, fn func()) {
+	if d.timer == nil {
+		d.timer = time.AfterFunc(window, fn)
+		return
+	}
+
+	d.timer.Reset(window)
+}
+
+// relevantKey reports whether key falls under one of the store's configured
+// prefixes, so notify can skip triggering a rebuild for a notification about
+// an object this store doesn't serve (e.g. a bucket or topic shared with
+// other prefixes/consumers).
+func (s *SnapshotStore) relevantKey(key string) bool {
+	for _, prefix := range s.prefixList() {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notificationKeys extracts every changed object's bucket key from a change
+// notification message, when the event schema makes them available. It
+// understands:
+//   - GCS Pub/Sub object notifications, which carry the object name in the
+//     "objectId" message attribute (always exactly one).
+//   - S3 bucket notifications delivered to SQS, whose body is the S3 event
+//     JSON with one or more Records[].s3.object.key entries (URL-encoded, as
+//     S3 sends them) — AWS batches multiple events into a single message on
+//     occasion, so all of them must be considered, not just the first.
+//
+// It reports ok == false when no key could be determined from either
+// schema, in which case the caller should treat the notification as
+// possibly relevant rather than drop it.
+func notificationKeys(msg *pubsub.Message) ([]string, bool) {
+	if key := msg.Metadata["objectId"]; key != "" {
+		return []string{key}, true
+	}
+
+	var event struct {
+		Records []struct {
+			S3 struct {
+				Object struct {
+					Key string `json:"key"`
+				} `json:"object"`
+			} `json:"s3"`
+		} `json:"Records"`
+	}
+	if err := json.Unmarshal(msg.Body, &event); err == nil && len(event.Records) > 0 {
+		keys := make([]string, 0, len(event.Records))
+		for _, r := range event.Records {
+			key, err := url.QueryUnescape(r.S3.Object.Key)
+			if err != nil || key == "" {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		if len(keys) > 0 {
+			return keys, true
+		}
+	}
+
+	return nil, false
+}
+
+// notify subscribes to the configured change notification source and
+// triggers a debounced snapshot update whenever a message arrives that's
+// relevant to one of the store's configured prefixes. It runs until ctx is
+// cancelled, logging and returning if the subscription cannot be opened.
+//
+// Relevance filtering is best-effort: notificationKeys only understands the
+// S3-via-SQS and GCS Pub/Sub event schemas, so a subscription fed by
+// something else (or a message missing the fields those schemas expect)
+// falls back to triggering a rebuild unconditionally, exactly like before
+// this filtering existed. update() is cheap to no-op when nothing under the
+// prefix actually changed, so this fallback only costs an extra poll's
+// worth of work, never correctness.
+func (s *SnapshotStore) notify(ctx context.Context) {
+	cfg := s.changeNotifications
+
+	sub, err := pubsub.OpenSubscription(ctx, cfg.SubscriptionURL)
+	if err != nil {
+		s.logger.Error("opening change notification subscription", zap.Error(err))
+		return
+	}
+	defer sub.Shutdown(context.Background())
+
+	var (
+		debounce debouncer
+		unhealth unhealthTracker
+	)
+
+	for {
+		msg, err := sub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			if unhealth.observeError(time.Now(), cfg.FallbackGrace) {
+				s.logger.Warn("change notification stream unavailable, relying on poller until it recovers", zap.Error(err))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(receiveRetryBackoff):
+			}
+
+			continue
+		}
+
+		unhealth.observeSuccess()
+		msg.Ack()
+
+		if keys, ok := notificationKeys(msg); ok {
+			relevant := false
+			for _, key := range keys {
+				if s.relevantKey(key) {
+					relevant = true
+					break
+				}
+			}
+			if !relevant {
+				continue
+			}
+		}
+
+		debounce.trigger(cfg.Debounce, s.onChangeNotification(ctx))
+	}
+}
+
+// onChangeNotification returns the function run once a debounce window
+// elapses without further notifications arriving.
+func (s *SnapshotStore) onChangeNotification(ctx context.Context) func() {
+	return func() {
+		if _, err := s.update(ctx); err != nil {
+			s.logger.Error("updating snapshot from change notification", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package object
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gocloud.dev/pubsub"
+)
+
+func TestDebouncer_CoalescesBurst(t *testing.T) {
+	var calls int32
+
+	var d debouncer
+	for i := 0; i < 5; i++ {
+		d.trigger(20*time.Millisecond, func() { atomic.AddInt32(&calls, 1) })
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("calls = %d, want 1 (burst should coalesce into a single trigger)", got)
+	}
+}
+
+func TestDebouncer_FiresAgainAfterWindowElapses(t *testing.T) {
+	var calls int32
+
+	var d debouncer
+	d.trigger(10*time.Millisecond, func() { atomic.AddInt32(&calls, 1) })
+	time.Sleep(30 * time.Millisecond)
+
+	d.trigger(10*time.Millisecond, func() { atomic.AddInt32(&calls, 1) })
+	time.Sleep(30 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("calls = %d, want 2 (separate windows should each fire)", got)
+	}
+}
+
+func TestUnhealthTracker_WarnsOnceAfterGrace(t *testing.T) {
+	var h unhealthTracker
+	start := time.Now()
+	grace := 10 * time.Second
+
+	if h.observeError(start, grace) {
+		t.Error("observeError() on first failure = true, want false (grace hasn't elapsed yet)")
+	}
+
+	if h.observeError(start.Add(5*time.Second), grace) {
+		t.Error("observeError() before grace elapses = true, want false")
+	}
+
+	if !h.observeError(start.Add(11*time.Second), grace) {
+		t.Error("observeError() after grace elapses = false, want true")
+	}
+
+	if h.observeError(start.Add(12*time.Second), grace) {
+		t.Error("observeError() after already warned = true, want false (should warn only once per unhealthy period)")
+	}
+}
+
+func TestUnhealthTracker_ResetsAfterSuccess(t *testing.T) {
+	var h unhealthTracker
+	start := time.Now()
+	grace := 10 * time.Second
+
+	h.observeError(start, grace)
+	h.observeError(start.Add(11*time.Second), grace)
+
+	h.observeSuccess()
+
+	if h.observeError(start.Add(12*time.Second), grace) {
+		t.Error("observeError() immediately after observeSuccess() = true, want false (period just started)")
+	}
+
+	if !h.observeError(start.Add(23*time.Second), grace) {
+		t.Error("observeError() after a fresh grace period elapses = false, want true")
+	}
+}
+
+func TestNotificationKeys_GCSAttribute(t *testing.T) {
+	msg := &pubsub.Message{Metadata: map[string]string{"objectId": "flags/shard-00/flags.features.yml"}}
+
+	keys, ok := notificationKeys(msg)
+	if !ok || len(keys) != 1 || keys[0] != "flags/shard-00/flags.features.yml" {
+		t.Errorf("notificationKeys() = (%v, %v), want ([flags/shard-00/flags.features.yml], true)", keys, ok)
+	}
+}
+
+func TestNotificationKeys_S3Event(t *testing.T) {
+	msg := &pubsub.Message{Body: []byte(`{"Records":[{"s3":{"object":{"key":"flags%2Fshard-00%2Fflags.features.yml"}}}]}`)}
+
+	keys, ok := notificationKeys(msg)
+	if !ok || len(keys) != 1 || keys[0] != "flags/shard-00/flags.features.yml" {
+		t.Errorf("notificationKeys() = (%v, %v), want ([flags/shard-00/flags.features.yml], true)", keys, ok)
+	}
+}
+
+func TestNotificationKeys_S3EventBatchedRecords(t *testing.T) {
+	msg := &pubsub.Message{Body: []byte(`{"Records":[
+		{"s3":{"object":{"key":"unrelated%2Fflags.features.yml"}}},
+		{"s3":{"object":{"key":"shard-00%2Fflags.features.yml"}}}
+	]}`)}
+
+	keys, ok := notificationKeys(msg)
+	if !ok || len(keys) != 2 {
+		t.Fatalf("notificationKeys() = (%v, %v), want both batched records' keys", keys, ok)
+	}
+	if keys[0] != "unrelated/flags.features.yml" || keys[1] != "shard-00/flags.features.yml" {
+		t.Errorf("notificationKeys() = %v, want both records' keys in order", keys)
+	}
+}
+
+func TestNotificationKeys_UnknownSchemaFallsBackToUnknown(t *testing.T) {
+	msg := &pubsub.Message{Body: []byte(`{"some":"other payload"}`)}
+
+	if _, ok := notificationKeys(msg); ok {
+		t.Error("notificationKeys() ok = true for an unrecognized payload, want false so the caller treats it as possibly relevant")
+	}
+}
+
+func TestRelevantKey(t *testing.T) {
+	s := &SnapshotStore{prefixes: []string{"shard-00/", "shard-01/"}}
+
+	if !s.relevantKey("shard-00/flags.features.yml") {
+		t.Error("relevantKey() = false for a key under a configured prefix, want true")
+	}
+
+	if s.relevantKey("unrelated/flags.features.yml") {
+		t.Error("relevantKey() = true for a key outside every configured prefix, want false")
+	}
+}
@@ -0,0 +1,320 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.flipt.io/flipt/internal/containers"
+	storagefs "go.flipt.io/flipt/internal/storage/fs"
+	gcblob "gocloud.dev/blob"
+	"golang.org/x/sync/errgroup"
+)
+
+// WithPrefixes configures the SnapshotStore to poll several prefixes and
+// union their matched objects into a single snapshot, instead of a single
+// WithPrefix. Each prefix consults its own index file and match set, so
+// .flipt.yml only needs to describe the objects under its own prefix.
+func WithPrefixes(prefixes []string) containers.Option[SnapshotStore] {
+	return func(s *SnapshotStore) {
+		s.prefixes = prefixes
+	}
+}
+
+// WithFetchConcurrency bounds how many objects are fetched concurrently
+// during a build. The default is 1, which fetches objects one at a time as
+// the store always has. Raising it trades off bucket request concurrency
+// for lower update latency on prefixes with many matched keys.
+func WithFetchConcurrency(n int) containers.Option[SnapshotStore] {
+	return func(s *SnapshotStore) {
+		s.fetchConcurrency = n
+	}
+}
+
+// prefixList returns the set of prefixes to poll, preferring the prefixes
+// configured via WithPrefixes and falling back to the single WithPrefix
+// value otherwise.
+func (s *SnapshotStore) prefixList() []string {
+	if len(s.prefixes) > 0 {
+		return s.prefixes
+	}
+
+	return []string{s.prefix}
+}
+
+// fetchConcurrencyLimit returns the configured fetch concurrency, or 1 if
+// unset, matching the store's historical serial fetch behaviour.
+func (s *SnapshotStore) fetchConcurrencyLimit() int {
+	if s.fetchConcurrency <= 0 {
+		return 1
+	}
+
+	return s.fetchConcurrency
+}
+
+// shardedKey identifies an object uniquely across all polled prefixes, used
+// as the key into the revision cache.
+type shardedKey struct {
+	prefix string
+	key    string
+}
+
+// fileKeyFor picks the name synthesized into the snapshot's fs.File
+// namespace for an object. With a single configured prefix it's the
+// prefix-relative key, unchanged from before sharding existed. With
+// several prefixes, two shards can legitimately contain an object with the
+// same relative name (e.g. every shard ships its own flags.yml), so the
+// full bucket key is used instead to keep names collision-free.
+func fileKeyFor(sharded bool, fullKey, relativeKey string) string {
+	if sharded {
+		return fullKey
+	}
+
+	return relativeKey
+}
+
+// revisionKeyFor is the key used into the revision cache and returned by
+// ObjectRevisions for a matched object: always its full bucket key, so two
+// shards that each contain an object with the same prefix-relative name
+// (e.g. every shard ships its own flags.yml) don't collide.
+func revisionKeyFor(prefix, key string) string {
+	return prefix + key
+}
+
+// revisionFromPrefixes derives a build's revision string from the index
+// file version of every configured prefix, so that a change confined to a
+// single sharded prefix still produces a revision distinct from the one
+// before it. A single configured prefix passes its index revision straight
+// through, unchanged from before sharding existed.
+func (s *SnapshotStore) revisionFromPrefixes(ctx context.Context, prefixes []string) string {
+	if len(prefixes) == 1 {
+		return s.indexRevision(ctx, prefixes[0])
+	}
+
+	revs := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		rev := s.indexRevision(ctx, prefix)
+		if rev == "" {
+			// One prefix's bucket can't report a version id; a partial
+			// combination would be more misleading than none, so fall
+			// back to the caller's mtime-derived revision entirely.
+			return ""
+		}
+		revs = append(revs, prefix+"="+rev)
+	}
+
+	return combineRevisions(revs)
+}
+
+// combineRevisions hashes a list of "prefix=revision" pairs into a single
+// opaque revision string, sorting them first so that reordering
+// WithPrefixes doesn't spuriously change the revision of an
+// otherwise-unchanged snapshot.
+func combineRevisions(revs []string) string {
+	sorted := append([]string(nil), revs...)
+	sort.Strings(sorted)
+
+	sum := sha256.Sum256([]byte(strings.Join(sorted, ";")))
+	return hex.EncodeToString(sum[:])
+}
+
+// fetchTask describes one matched object to retrieve during a build: the
+// sharded key it's listed under, the name it's synthesized into the
+// snapshot's fs.File namespace as, and its listed metadata.
+type fetchTask struct {
+	shardedKey
+	fileKey string
+	item    *gcblob.ListObject
+}
+
+// listFetchTasks lists every configured prefix, consulting each one's own
+// index, and returns the matched objects to fetch. The returned order is
+// deterministic — grouped by prefix in configuration order, then by key
+// within a prefix — and that's what makes the later concurrent fetch safe:
+// fetchTasks writes each task's result into a fixed slice position rather
+// than appending on completion, so this listing order is also the final
+// snapshot order regardless of which fetch finishes first.
+func (s *SnapshotStore) listFetchTasks(ctx context.Context) ([]fetchTask, bool, time.Time, error) {
+	sharded := len(s.prefixList()) > 1
+
+	var (
+		tasks      []fetchTask
+		maxModTime time.Time
+	)
+
+	for _, prefix := range s.prefixList() {
+		idx, err := s.getIndex(ctx, prefix)
+		if err != nil {
+			return nil, sharded, time.Time{}, err
+		}
+
+		iterator := s.bucket.List(&gcblob.ListOptions{Prefix: prefix})
+		for {
+			item, err := iterator.Next(ctx)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, sharded, time.Time{}, err
+			}
+
+			key := strings.TrimPrefix(item.Key, prefix)
+			if !idx.Match(key) {
+				continue
+			}
+
+			if item.ModTime.After(maxModTime) {
+				maxModTime = item.ModTime
+			}
+
+			tasks = append(tasks, fetchTask{
+				shardedKey: shardedKey{prefix: prefix, key: key},
+				fileKey:    fileKeyFor(sharded, item.Key, key),
+				item:       item,
+			})
+		}
+	}
+
+	return tasks, sharded, maxModTime, nil
+}
+
+// fetchTasks retrieves each task's contents through a bounded worker pool,
+// reusing prev's cached bytes for any task whose version hasn't changed.
+// changed is false only when every task was reused from prev and prev had
+// no other keys besides the ones tasks describes. It doesn't touch
+// s.revisions itself — on error, the caller's existing revision cache is
+// left exactly as it was, so a partial fetch failure can't corrupt it.
+func (s *SnapshotStore) fetchTasks(ctx context.Context, tasks []fetchTask, prev map[string]objectRevision) ([]fs.File, map[string]objectRevision, bool, error) {
+	var (
+		mu        sync.Mutex
+		files     = make([]fs.File, len(tasks))
+		revisions = make(map[string]objectRevision, len(tasks))
+		changed   = prev == nil
+	)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(s.fetchConcurrencyLimit())
+
+	for i, t := range tasks {
+		i, t := i, t
+		revKey := revisionKeyFor(t.prefix, t.key)
+
+		g.Go(func() error {
+			version := objectVersion(t.item)
+
+			if rev, ok := prev[revKey]; ok && rev.version == version {
+				s.metrics.hits.Add(gctx, 1)
+				files[i] = NewFile(t.fileKey, rev.size, io.NopCloser(bytes.NewReader(rev.data)), rev.modTime)
+
+				mu.Lock()
+				revisions[revKey] = rev
+				mu.Unlock()
+
+				return nil
+			}
+
+			if _, ok := prev[revKey]; ok {
+				s.metrics.changed.Add(gctx, 1)
+			} else {
+				s.metrics.misses.Add(gctx, 1)
+			}
+
+			mu.Lock()
+			changed = true
+			mu.Unlock()
+
+			rd, err := s.bucket.NewReader(gctx, t.prefix+t.key, &gcblob.ReaderOptions{})
+			if err != nil {
+				return err
+			}
+
+			data, err := io.ReadAll(rd)
+			rd.Close()
+			if err != nil {
+				return err
+			}
+
+			files[i] = NewFile(t.fileKey, t.item.Size, io.NopCloser(bytes.NewReader(data)), t.item.ModTime)
+
+			mu.Lock()
+			revisions[revKey] = objectRevision{
+				version: version,
+				modTime: t.item.ModTime,
+				size:    t.item.Size,
+				data:    data,
+			}
+			mu.Unlock()
+
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, nil, false, err
+	}
+
+	if len(revisions) != len(prev) {
+		changed = true
+	}
+
+	return files, revisions, changed, nil
+}
+
+// buildFromPrefixes lists every configured prefix, consulting each one's own
+// index, and fetches the matched objects through a bounded worker pool.
+// Object order in the resulting snapshot is deterministic — grouped by
+// prefix in configuration order, then by key within a prefix — regardless
+// of which fetch completes first, so snapshot hashes stay stable.
+func (s *SnapshotStore) buildFromPrefixes(ctx context.Context) (buildResult, error) {
+	tasks, sharded, maxModTime, err := s.listFetchTasks(ctx)
+	if err != nil {
+		return buildResult{}, err
+	}
+
+	s.mu.RLock()
+	prev := s.revisions
+	s.mu.RUnlock()
+
+	files, revisions, changed, err := s.fetchTasks(ctx, tasks, prev)
+	if err != nil {
+		return buildResult{}, err
+	}
+
+	if !changed {
+		return buildResult{}, nil
+	}
+
+	snap, err := storagefs.SnapshotFromFiles(s.logger, files...)
+	if err != nil {
+		return buildResult{}, err
+	}
+
+	s.mu.Lock()
+	s.revisions = revisions
+	s.mu.Unlock()
+
+	revision := s.revisionFromPrefixes(ctx, s.prefixList())
+	if revision == "" {
+		revision = revisionFromModTime(maxModTime)
+	}
+
+	// A bucket-native version history for the index file only makes sense
+	// when there's exactly one unambiguous index file to track; each
+	// sharded prefix has its own independent version timeline that doesn't
+	// compose into a single one, so history falls back to whatever this
+	// process builds at runtime for those.
+	var versionKey string
+	if !sharded {
+		versionKey = s.prefixList()[0] + storagefs.IndexFileName
+	}
+
+	return buildResult{snap: snap, changed: true, revision: revision, modTime: maxModTime, versionKey: versionKey}, nil
+}
@@ -0,0 +1,170 @@
+package object
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"gocloud.dev/blob/memblob"
+)
+
+func TestFileKeyFor(t *testing.T) {
+	tests := []struct {
+		name        string
+		sharded     bool
+		fullKey     string
+		relativeKey string
+		want        string
+	}{
+		{
+			name:        "single prefix keeps the prefix-relative key",
+			sharded:     false,
+			fullKey:     "flags/shard-00/flags.yml",
+			relativeKey: "flags.yml",
+			want:        "flags.yml",
+		},
+		{
+			name:        "multiple prefixes use the full bucket key to avoid collisions",
+			sharded:     true,
+			fullKey:     "flags/shard-00/flags.yml",
+			relativeKey: "flags.yml",
+			want:        "flags/shard-00/flags.yml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fileKeyFor(tt.sharded, tt.fullKey, tt.relativeKey); got != tt.want {
+				t.Errorf("fileKeyFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileKeyFor_DisambiguatesAcrossShards(t *testing.T) {
+	a := fileKeyFor(true, "flags/shard-00/flags.yml", "flags.yml")
+	b := fileKeyFor(true, "flags/shard-01/flags.yml", "flags.yml")
+
+	if a == b {
+		t.Fatalf("expected distinct file keys for same relative name across shards, got %q for both", a)
+	}
+}
+
+func TestRevisionKeyFor_DisambiguatesAcrossShards(t *testing.T) {
+	a := revisionKeyFor("flags/shard-00/", "flags.yml")
+	b := revisionKeyFor("flags/shard-01/", "flags.yml")
+
+	if a == b {
+		t.Fatalf("expected distinct revision keys for same relative name across shards, got %q for both", a)
+	}
+
+	if a != "flags/shard-00/flags.yml" {
+		t.Errorf("revisionKeyFor() = %q, want the full bucket key %q", a, "flags/shard-00/flags.yml")
+	}
+}
+
+func TestCombineRevisions(t *testing.T) {
+	a := combineRevisions([]string{"shard-00=v1", "shard-01=v2"})
+	b := combineRevisions([]string{"shard-01=v2", "shard-00=v1"})
+
+	if a != b {
+		t.Errorf("combineRevisions() is order-dependent: %q != %q", a, b)
+	}
+
+	if a == "" {
+		t.Error("combineRevisions() = \"\", want a non-empty hash")
+	}
+
+	changed := combineRevisions([]string{"shard-00=v1", "shard-01=v3"})
+	if changed == a {
+		t.Error("combineRevisions() did not change when one prefix's revision changed")
+	}
+}
+
+func TestBuildFromPrefixes_UnionsShardsWithConcurrentFetch(t *testing.T) {
+	ctx := context.Background()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	seed := map[string]string{
+		"shard-00/flags.features.yml":    "namespace: shard-00\nflags: []\n",
+		"shard-01/flags.features.yml":    "namespace: shard-01\nflags: []\n",
+		"shard-01/segments.features.yml": "namespace: shard-01\nflags: []\n",
+	}
+	for key, content := range seed {
+		if err := bucket.WriteAll(ctx, key, []byte(content), nil); err != nil {
+			t.Fatalf("seeding %q: %v", key, err)
+		}
+	}
+
+	s := newTestStore(t, bucket)
+	s.prefixes = []string{"shard-00/", "shard-01/"}
+	s.fetchConcurrency = 4
+
+	res, err := s.buildFromPrefixes(ctx)
+	if err != nil {
+		t.Fatalf("buildFromPrefixes() error = %v", err)
+	}
+	if !res.changed {
+		t.Fatal("buildFromPrefixes() changed = false on first build, want true")
+	}
+
+	got := s.ObjectRevisions()
+	for key := range seed {
+		if _, ok := got[key]; !ok {
+			t.Errorf("ObjectRevisions() missing %q, want every shard's matched objects unioned by full bucket key", key)
+		}
+	}
+	if len(got) != len(seed) {
+		t.Errorf("ObjectRevisions() has %d entries, want %d (one per matched object across both shards)", len(got), len(seed))
+	}
+
+	// A second build with nothing changed must report changed = false, the
+	// same contract as the single-prefix path, to show sharding didn't
+	// regress revision-cache reuse.
+	res, err = s.buildFromPrefixes(ctx)
+	if err != nil {
+		t.Fatalf("buildFromPrefixes() error = %v", err)
+	}
+	if res.changed {
+		t.Error("buildFromPrefixes() changed = true on second build with no bucket changes, want false")
+	}
+}
+
+func TestFetchTasks_FailedFetchLeavesCallerRevisionsUntouched(t *testing.T) {
+	ctx := context.Background()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	if err := bucket.WriteAll(ctx, "flags.features.yml", []byte("namespace: default\nflags: []\n"), nil); err != nil {
+		t.Fatalf("seeding bucket: %v", err)
+	}
+
+	s := newTestStore(t, bucket)
+
+	tasks, _, _, err := s.listFetchTasks(ctx)
+	if err != nil {
+		t.Fatalf("listFetchTasks() error = %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("listFetchTasks() returned %d tasks, want 1", len(tasks))
+	}
+
+	prev := map[string]objectRevision{"flags.features.yml": {version: "stale"}}
+
+	// Simulate the object vanishing between listing and fetch (a real race
+	// against a concurrent delete on the bucket): the task still describes
+	// it, but the GET now fails.
+	if err := bucket.Delete(ctx, "flags.features.yml"); err != nil {
+		t.Fatalf("deleting bucket object: %v", err)
+	}
+
+	_, _, _, err = s.fetchTasks(ctx, tasks, prev)
+	if err == nil {
+		t.Fatal("fetchTasks() error = nil, want an error fetching a since-deleted object")
+	}
+
+	if _, ok := prev["flags.features.yml"]; !ok {
+		t.Error("fetchTasks() mutated the caller's prev revisions map on failure, want it left untouched")
+	}
+}
@@ -2,15 +2,15 @@ package object
 
 import (
 	"context"
-	"errors"
+	"encoding/hex"
 	"fmt"
-	"io"
 	"io/fs"
 	"net/url"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
+	gcsstorage "cloud.google.com/go/storage"
 	s3v2 "github.com/aws/aws-sdk-go-v2/service/s3"
 	"go.flipt.io/flipt/internal/containers"
 	"go.flipt.io/flipt/internal/storage"
@@ -52,10 +52,47 @@ type SnapshotStore struct {
 	scheme   string
 	bucket   *gcblob.Bucket
 	prefix   string
+	prefixes []string
 	pollOpts []containers.Option[storagefs.Poller]
 
-	mu   sync.RWMutex
-	snap storage.ReadOnlyStore
+	changeNotifications *ChangeNotifications
+	metrics             *storeMetrics
+	bundleKey           string
+	fetchConcurrency    int
+	history             int
+	bucketName          string
+
+	// updateMu serializes update, since both the Poller and the
+	// change-notification consumer (notify.go) call it directly with no
+	// other coordination between them. retain assumes it's only ever
+	// called with builds in chronological order; two concurrent updates
+	// finishing out of order would retain snapshots out of sequence and
+	// break the newest-first invariant ViewAt's scan relies on.
+	updateMu sync.Mutex
+
+	mu        sync.RWMutex
+	snap      storage.ReadOnlyStore
+	revisions map[string]objectRevision
+	retained  []retainedSnapshot
+}
+
+// objectRevision captures enough metadata about a previously fetched object
+// to detect whether it has changed on a subsequent poll, and its parsed
+// contents so they can be reused without re-fetching when it hasn't.
+type objectRevision struct {
+	// version identifies the object's content, derived from its MD5
+	// checksum when the bucket reports one, or its ModTime otherwise.
+	version string
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+// ObjectRevision is the observability-facing view of an objectRevision,
+// returned by (*SnapshotStore).ObjectRevisions.
+type ObjectRevision struct {
+	Version string
+	ModTime time.Time
 }
 
 func NewSnapshotStore(ctx context.Context, logger *zap.Logger, scheme string, bucket *gcblob.Bucket, opts ...containers.Option[SnapshotStore]) (*SnapshotStore, error) {
@@ -70,6 +107,12 @@ func NewSnapshotStore(ctx context.Context, logger *zap.Logger, scheme string, bu
 
 	containers.ApplyAll(s, opts...)
 
+	m, err := newStoreMetrics()
+	if err != nil {
+		return nil, err
+	}
+	s.metrics = m
+
 	// fetch snapshot at-least once before returning store
 	// to ensure we have some state to serve
 	if _, err := s.update(ctx); err != nil {
@@ -80,6 +123,10 @@ func NewSnapshotStore(ctx context.Context, logger *zap.Logger, scheme string, bu
 
 	go s.Poll()
 
+	if s.changeNotifications != nil {
+		go s.notify(ctx)
+	}
+
 	return s, nil
 }
 
@@ -110,63 +157,135 @@ func (s *SnapshotStore) String() string {
 	return s.scheme
 }
 
-// Update fetches a new snapshot and swaps it out for the current one.
+// Update fetches a new snapshot and swaps it out for the current one. It
+// returns false if none of the matched objects have changed since the last
+// update, in which case the existing snapshot is left in place.
+//
+// update holds updateMu for its whole body, since both the Poller and the
+// change-notification consumer call it directly and a build/retain cycle
+// must complete before the next one starts.
 func (s *SnapshotStore) update(ctx context.Context) (bool, error) {
-	snap, err := s.build(ctx)
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+
+	res, err := s.build(ctx)
 	if err != nil {
 		return false, err
 	}
 
+	if !res.changed {
+		return false, nil
+	}
+
 	s.mu.Lock()
-	s.snap = snap
+	s.snap = res.snap
 	s.mu.Unlock()
 
+	s.retain(ctx, res.revision, res.modTime, res.snap, res.versionKey)
+
 	return true, nil
 }
 
-func (s *SnapshotStore) build(ctx context.Context) (*storagefs.Snapshot, error) {
-	idx, err := s.getIndex(ctx)
+// buildResult is returned by a build strategy (prefix listing or bundle)
+// with enough detail for update to decide whether to swap the snapshot, and
+// to retain it in history.
+type buildResult struct {
+	snap     *storagefs.Snapshot
+	changed  bool
+	revision string
+	modTime  time.Time
+	// versionKey is the full bucket key whose object-version history
+	// stands in for the snapshot's own history: the index file for
+	// prefix-listing builds, the bundle object for bundle builds. Empty
+	// when the build strategy can't identify one unambiguous key to track
+	// (e.g. several sharded prefixes, each with its own independent index
+	// history), in which case retain falls back to the runtime-accumulated
+	// history window.
+	versionKey string
+}
+
+// build constructs a new snapshot, either from the configured bundle object
+// or by listing and fetching the configured prefix(es). changed is false
+// only when every matched key was reused unchanged from the revision cache
+// and none were added or removed.
+func (s *SnapshotStore) build(ctx context.Context) (buildResult, error) {
+	if s.bundleKey != "" {
+		return s.buildFromBundle(ctx)
+	}
+
+	return s.buildFromPrefixes(ctx)
+}
+
+// objectVersion derives a stable fingerprint for a listed object, preferring
+// its MD5 checksum when the bucket reports one and falling back to its
+// ModTime otherwise.
+func objectVersion(item *gcblob.ListObject) string {
+	if len(item.MD5) > 0 {
+		return hex.EncodeToString(item.MD5)
+	}
+	return item.ModTime.String()
+}
+
+// objectVersionFromAttrs is objectVersion for a single object fetched via
+// bucket.Attributes rather than bucket.List.
+func objectVersionFromAttrs(attrs *gcblob.Attributes) string {
+	if len(attrs.MD5) > 0 {
+		return hex.EncodeToString(attrs.MD5)
+	}
+	return attrs.ModTime.String()
+}
+
+// indexRevision asks the bucket directly for the version identifier of a
+// prefix's index file, falling back to "" when the bucket can't report one.
+func (s *SnapshotStore) indexRevision(ctx context.Context, prefix string) string {
+	attrs, err := s.bucket.Attributes(ctx, prefix+storagefs.IndexFileName)
 	if err != nil {
-		return nil, err
+		return ""
 	}
 
-	iterator := s.bucket.List(&gcblob.ListOptions{
-		Prefix: s.prefix,
-	})
+	return versionIDFromAttrs(attrs)
+}
 
-	var files []fs.File
-	for {
-		item, err := iterator.Next(ctx)
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				break
-			}
-			return nil, err
-		}
+// versionIDFromAttrs extracts the bucket's own object-version identifier:
+// the S3 VersionId or the GCS object Generation, unwrapped from the
+// driver-specific response via Attributes.As. It returns "" when the
+// underlying driver is neither, or an S3 bucket has versioning disabled, in
+// which case callers fall back to a ModTime-derived revision. GCS reports a
+// generation for every object, whether or not versioning is enabled.
+func versionIDFromAttrs(attrs *gcblob.Attributes) string {
+	var head s3v2.HeadObjectOutput
+	if attrs.As(&head) && head.VersionId != nil {
+		return *head.VersionId
+	}
 
-		key := strings.TrimPrefix(item.Key, s.prefix)
-		if !idx.Match(key) {
-			continue
-		}
+	var gcsAttrs gcsstorage.ObjectAttrs
+	if attrs.As(&gcsAttrs) && gcsAttrs.Generation != 0 {
+		return strconv.FormatInt(gcsAttrs.Generation, 10)
+	}
 
-		rd, err := s.bucket.NewReader(ctx, s.prefix+key, &gcblob.ReaderOptions{})
-		if err != nil {
-			return nil, err
-		}
+	return ""
+}
+
+// ObjectRevisions returns the version metadata observed for each matched
+// object as of the last successful build, keyed by its full bucket key (see
+// revisionKeyFor), not a key relative to s.prefix, since a sharded
+// multi-prefix build can otherwise have two matched objects share the same
+// relative name. It is intended for observability (e.g. debug endpoints),
+// not as a stable API for consumers to branch on.
+func (s *SnapshotStore) ObjectRevisions() map[string]ObjectRevision {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-		files = append(files, NewFile(
-			key,
-			item.Size,
-			rd,
-			item.ModTime,
-		))
+	revisions := make(map[string]ObjectRevision, len(s.revisions))
+	for k, v := range s.revisions {
+		revisions[k] = ObjectRevision{Version: v.version, ModTime: v.modTime}
 	}
 
-	return storagefs.SnapshotFromFiles(s.logger, files...)
+	return revisions
 }
 
-func (s *SnapshotStore) getIndex(ctx context.Context) (*storagefs.FliptIndex, error) {
-	rd, err := s.bucket.NewReader(ctx, s.prefix+storagefs.IndexFileName, &gcblob.ReaderOptions{})
+func (s *SnapshotStore) getIndex(ctx context.Context, prefix string) (*storagefs.FliptIndex, error) {
+	rd, err := s.bucket.NewReader(ctx, prefix+storagefs.IndexFileName, &gcblob.ReaderOptions{})
 	if err == nil {
 		idx, err := storagefs.ParseFliptIndex(rd)
 		if err != nil {
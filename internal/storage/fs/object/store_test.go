@@ -0,0 +1,118 @@
+package object
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/memblob"
+)
+
+func newTestStore(t *testing.T, bucket *blob.Bucket) *SnapshotStore {
+	t.Helper()
+
+	m, err := newStoreMetrics()
+	if err != nil {
+		t.Fatalf("newStoreMetrics() error = %v", err)
+	}
+
+	return &SnapshotStore{logger: zap.NewNop(), bucket: bucket, metrics: m}
+}
+
+func TestUpdate_ReturnsUnchangedWhenNothingChanged(t *testing.T) {
+	ctx := context.Background()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	if err := bucket.WriteAll(ctx, "flags.features.yml", []byte("namespace: default\nflags: []\n"), nil); err != nil {
+		t.Fatalf("seeding bucket: %v", err)
+	}
+
+	s := newTestStore(t, bucket)
+
+	changed, err := s.update(ctx)
+	if err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+	if !changed {
+		t.Fatal("update() changed = false on first build, want true")
+	}
+
+	changed, err = s.update(ctx)
+	if err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+	if changed {
+		t.Error("update() changed = true on second build with no bucket changes, want false")
+	}
+}
+
+func TestUpdate_ReportsChangedWhenAnObjectChanges(t *testing.T) {
+	ctx := context.Background()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	if err := bucket.WriteAll(ctx, "flags.features.yml", []byte("namespace: default\nflags: []\n"), nil); err != nil {
+		t.Fatalf("seeding bucket: %v", err)
+	}
+
+	s := newTestStore(t, bucket)
+
+	if _, err := s.update(ctx); err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+
+	if err := bucket.WriteAll(ctx, "flags.features.yml", []byte("namespace: default\nflags:\n  - key: new-flag\n"), nil); err != nil {
+		t.Fatalf("updating bucket object: %v", err)
+	}
+
+	changed, err := s.update(ctx)
+	if err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+	if !changed {
+		t.Error("update() changed = false after an object's content changed, want true")
+	}
+}
+
+func TestUpdate_ReusesCachedDataForUnchangedObjects(t *testing.T) {
+	ctx := context.Background()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	if err := bucket.WriteAll(ctx, "flags.features.yml", []byte("namespace: default\nflags: []\n"), nil); err != nil {
+		t.Fatalf("seeding bucket: %v", err)
+	}
+	if err := bucket.WriteAll(ctx, "segments.features.yml", []byte("namespace: segments\nflags: []\n"), nil); err != nil {
+		t.Fatalf("seeding bucket: %v", err)
+	}
+
+	s := newTestStore(t, bucket)
+
+	if _, err := s.update(ctx); err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+
+	before, ok := s.ObjectRevisions()["segments.features.yml"]
+	if !ok {
+		t.Fatal(`ObjectRevisions()["segments.features.yml"] missing after first build, want it matched and tracked`)
+	}
+
+	if err := bucket.WriteAll(ctx, "flags.features.yml", []byte("namespace: default\nflags:\n  - key: new-flag\n"), nil); err != nil {
+		t.Fatalf("updating bucket object: %v", err)
+	}
+
+	if _, err := s.update(ctx); err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+
+	after, ok := s.ObjectRevisions()["segments.features.yml"]
+	if !ok {
+		t.Fatal(`ObjectRevisions()["segments.features.yml"] missing after second build, want it matched and tracked`)
+	}
+
+	if before != after {
+		t.Errorf("ObjectRevisions()[%q] changed from %+v to %+v for an object that wasn't touched", "segments.features.yml", before, after)
+	}
+}